@@ -0,0 +1,119 @@
+// Package cache implements a bounded, on-disk LRU cache for finished
+// archives, keyed by an opaque string the caller derives from the repo
+// URL, resolved commit SHA, sub-path, and output format.
+package cache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Cache stores finished archives under a directory, evicting the least
+// recently used entries once MaxBytes or MaxEntries is exceeded.
+type Cache struct {
+	Dir        string
+	MaxBytes   int64
+	MaxEntries int
+}
+
+// New returns a Cache rooted at dir, creating it if necessary.
+func New(dir string, maxBytes int64, maxEntries int) (*Cache, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("cache: create dir %s: %w", dir, err)
+	}
+	return &Cache{Dir: dir, MaxBytes: maxBytes, MaxEntries: maxEntries}, nil
+}
+
+// Key derives an opaque, filesystem-safe cache key from the repo the
+// archive was built from, the resolved commit SHA, the requested
+// sub-path, and the output format. repoURL must be included: a commit
+// SHA alone is not a secret (it leaks via PR notifications, CI logs,
+// etc.), so keying on SHA alone would let anyone who knows a SHA ever
+// archived for one repo fetch it again under a different repo name.
+func Key(repoURL, sha, subPath, format string) string {
+	sum := sha256.Sum256([]byte(repoURL + "\x00" + sha + "\x00" + subPath + "\x00" + format))
+	return hex.EncodeToString(sum[:])
+}
+
+// Path returns the on-disk location of the entry for key, whether or
+// not it currently exists.
+func (c *Cache) Path(key string) string {
+	return filepath.Join(c.Dir, key)
+}
+
+// Lookup reports whether key is cached, returning its path if so. A
+// successful lookup refreshes the entry's recency for LRU purposes.
+func (c *Cache) Lookup(key string) (path string, ok bool) {
+	path = c.Path(key)
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", false
+	}
+	now := time.Now()
+	os.Chtimes(path, now, now)
+	_ = info
+	return path, true
+}
+
+// TempFile creates a new temporary file inside the cache directory
+// (so Put's rename is atomic and same-filesystem) for the caller to
+// write a finished archive into before committing it with Put.
+func (c *Cache) TempFile() (*os.File, error) {
+	return os.CreateTemp(c.Dir, ".tmp-*")
+}
+
+// Put atomically moves tmpPath (as returned by TempFile) into the cache
+// under key, then evicts older entries if the cache is over its caps.
+func (c *Cache) Put(tmpPath, key string) (string, error) {
+	dest := c.Path(key)
+	if err := os.Rename(tmpPath, dest); err != nil {
+		return "", fmt.Errorf("cache: commit entry %s: %w", key, err)
+	}
+	c.evict()
+	return dest, nil
+}
+
+// evict removes the least recently used entries until the cache is
+// within MaxBytes and MaxEntries. Eviction errors are best-effort: a
+// failed removal just leaves the cache briefly over its cap.
+func (c *Cache) evict() {
+	entries, err := os.ReadDir(c.Dir)
+	if err != nil {
+		return
+	}
+
+	type item struct {
+		path    string
+		size    int64
+		modTime time.Time
+	}
+	var items []item
+	var total int64
+	for _, e := range entries {
+		if e.IsDir() || strings.HasPrefix(e.Name(), ".tmp-") {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			continue
+		}
+		items = append(items, item{filepath.Join(c.Dir, e.Name()), info.Size(), info.ModTime()})
+		total += info.Size()
+	}
+
+	sort.Slice(items, func(i, j int) bool { return items[i].modTime.Before(items[j].modTime) })
+
+	for len(items) > 0 && (total > c.MaxBytes || len(items) > c.MaxEntries) {
+		oldest := items[0]
+		if err := os.Remove(oldest.path); err == nil {
+			total -= oldest.size
+		}
+		items = items[1:]
+	}
+}
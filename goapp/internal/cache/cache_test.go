@@ -0,0 +1,71 @@
+package cache
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func writeEntry(t *testing.T, c *Cache, key string, size int, modTime time.Time) {
+	t.Helper()
+	path := c.Path(key)
+	if err := os.WriteFile(path, make([]byte, size), 0o644); err != nil {
+		t.Fatalf("write entry %s: %v", key, err)
+	}
+	if err := os.Chtimes(path, modTime, modTime); err != nil {
+		t.Fatalf("chtimes entry %s: %v", key, err)
+	}
+}
+
+func TestEvictRemovesLeastRecentlyUsedFirst(t *testing.T) {
+	dir := t.TempDir()
+	c, err := New(dir, 25, 100)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	now := time.Now()
+	writeEntry(t, c, "oldest", 10, now.Add(-2*time.Hour))
+	writeEntry(t, c, "middle", 10, now.Add(-1*time.Hour))
+	writeEntry(t, c, "newest", 10, now)
+
+	c.evict()
+
+	if _, err := os.Stat(filepath.Join(dir, "oldest")); !os.IsNotExist(err) {
+		t.Errorf("oldest entry should have been evicted, stat err = %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "middle")); err != nil {
+		t.Errorf("middle entry should still be present: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "newest")); err != nil {
+		t.Errorf("newest entry should still be present: %v", err)
+	}
+}
+
+func TestLookupRefreshesRecency(t *testing.T) {
+	dir := t.TempDir()
+	c, err := New(dir, 1<<20, 1)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	old := time.Now().Add(-1 * time.Hour)
+	writeEntry(t, c, "a", 10, old)
+	writeEntry(t, c, "b", 10, old.Add(time.Minute))
+
+	if _, ok := c.Lookup("a"); !ok {
+		t.Fatalf("Lookup(a): want hit")
+	}
+
+	// MaxEntries is 1, so evict() must keep only one entry: "a" was just
+	// refreshed by Lookup, so "b" (now the least recently used) should go.
+	c.evict()
+
+	if _, err := os.Stat(filepath.Join(dir, "a")); err != nil {
+		t.Errorf("a should still be present after refresh: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "b")); !os.IsNotExist(err) {
+		t.Errorf("b should have been evicted, stat err = %v", err)
+	}
+}
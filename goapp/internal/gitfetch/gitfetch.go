@@ -0,0 +1,265 @@
+// Package gitfetch fetches a single ref (and optionally a single
+// subdirectory) of a remote git repository using the smart HTTP git
+// protocol, without pulling the full history or the full tree.
+package gitfetch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// ErrTooLarge is returned (wrapped) by Fetch when the working directory
+// grows past opts.MaxBytes while the fetch and checkout are in
+// progress, the git-backend equivalent of the zip backend's download
+// size cap.
+var ErrTooLarge = errors.New("gitfetch: repository exceeds configured size limit")
+
+// dirSizePollInterval is how often the MaxBytes watchdog checks the
+// working directory's size while a fetch or checkout is running.
+const dirSizePollInterval = 200 * time.Millisecond
+
+// Options controls how Fetch materializes the repository on disk.
+type Options struct {
+	// BaseDir is the parent directory the clone is created under. If
+	// empty, os.MkdirTemp's default temp directory is used.
+	BaseDir string
+
+	// MaxBytes bounds how large the working directory is allowed to
+	// grow while being fetched and checked out. Zero means unlimited.
+	// Unlike writeArchive's post-hoc limits, this is enforced while git
+	// is still running, since the git backend's own fetch/checkout has
+	// no download-size cap of its own.
+	MaxBytes int64
+}
+
+// Fetch materializes ref (a branch, tag, or commit SHA) of repoURL into
+// a new directory under opts.BaseDir, then narrows the checkout to
+// subPath (if non-empty) via sparse-checkout. It returns the path to
+// the root of the local working tree, even when subPath was requested;
+// subPath lies beneath it.
+// authHeader (as returned by a provider.Provider's AuthHeader), if
+// non-empty, is forwarded on the fetch so private repositories work the
+// same way they do through the zip backend.
+//
+// Unlike `git clone --branch`, which only accepts branch and tag names,
+// this fetches ref directly so a commit SHA works too, relying on the
+// remote advertising it as a reachable want (GitHub, GitLab, and
+// Bitbucket all allow this for public repositories).
+func Fetch(ctx context.Context, repoURL, ref, subPath string, authHeader http.Header, opts Options) (string, error) {
+	localDir, err := os.MkdirTemp(opts.BaseDir, "gitfetch-")
+	if err != nil {
+		return "", fmt.Errorf("gitfetch: create working dir: %w", err)
+	}
+
+	if err := runGit(ctx, localDir, "init", "--quiet"); err != nil {
+		os.RemoveAll(localDir)
+		return "", fmt.Errorf("gitfetch: init: %w", err)
+	}
+	if err := runGit(ctx, localDir, "remote", "add", "origin", repoURL); err != nil {
+		os.RemoveAll(localDir)
+		return "", fmt.Errorf("gitfetch: add remote: %w", err)
+	}
+
+	if subPath != "" {
+		if err := runGit(ctx, localDir, "sparse-checkout", "init", "--cone"); err != nil {
+			os.RemoveAll(localDir)
+			return "", fmt.Errorf("gitfetch: sparse-checkout init: %w", err)
+		}
+		if err := runGit(ctx, localDir, "sparse-checkout", "set", "--", subPath); err != nil {
+			os.RemoveAll(localDir)
+			return "", fmt.Errorf("gitfetch: sparse-checkout set %s: %w", subPath, err)
+		}
+	}
+
+	fetchCtx := ctx
+	var tooLarge int32
+	if opts.MaxBytes > 0 {
+		var cancel context.CancelFunc
+		fetchCtx, cancel = context.WithCancel(ctx)
+		defer cancel()
+		go watchDirSize(fetchCtx, cancel, localDir, opts.MaxBytes, &tooLarge)
+	}
+
+	if err := runGit(fetchCtx, localDir, authArgs(authHeader, "fetch",
+		"--depth=1",
+		"--filter=blob:none",
+		"--no-tags",
+		"origin", "--", ref,
+	)...); err != nil {
+		os.RemoveAll(localDir)
+		if atomic.LoadInt32(&tooLarge) == 1 {
+			return "", fmt.Errorf("gitfetch: fetch %s@%s: %w", repoURL, ref, ErrTooLarge)
+		}
+		return "", fmt.Errorf("gitfetch: fetch %s@%s: %w", repoURL, ref, err)
+	}
+	// The fetch above skipped blobs, so checkout fetches the ones it
+	// needs from origin and has to carry the same credentials.
+	if err := runGit(fetchCtx, localDir, authArgs(authHeader, "checkout", "--quiet", "FETCH_HEAD")...); err != nil {
+		os.RemoveAll(localDir)
+		if atomic.LoadInt32(&tooLarge) == 1 {
+			return "", fmt.Errorf("gitfetch: checkout %s@%s: %w", repoURL, ref, ErrTooLarge)
+		}
+		return "", fmt.Errorf("gitfetch: checkout %s@%s: %w", repoURL, ref, err)
+	}
+	if atomic.LoadInt32(&tooLarge) == 1 {
+		os.RemoveAll(localDir)
+		return "", fmt.Errorf("gitfetch: %s@%s: %w", repoURL, ref, ErrTooLarge)
+	}
+
+	return localDir, nil
+}
+
+// watchDirSize polls dir's total size every dirSizePollInterval and,
+// the moment it crosses maxBytes, stores 1 into exceeded and calls
+// cancel to abort the in-flight git subprocess. It returns once ctx is
+// done (the git command finished, one way or another) without needing
+// its own cancellation.
+func watchDirSize(ctx context.Context, cancel context.CancelFunc, dir string, maxBytes int64, exceeded *int32) {
+	ticker := time.NewTicker(dirSizePollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if size, err := dirSize(dir); err == nil && size > maxBytes {
+				atomic.StoreInt32(exceeded, 1)
+				cancel()
+				return
+			}
+		}
+	}
+}
+
+// dirSize sums the size of every regular file under dir.
+func dirSize(dir string) (int64, error) {
+	var total int64
+	err := filepath.Walk(dir, func(_ string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !fi.IsDir() {
+			total += fi.Size()
+		}
+		return nil
+	})
+	return total, err
+}
+
+// authArgs prepends `-c http.extraHeader=...` git config overrides for
+// each entry in authHeader ahead of args, so a single invocation of git
+// carries the caller's credentials on whichever HTTP requests it makes.
+// git applies repeated http.extraHeader values additively, so multiple
+// headers (or multiple values for one header) all survive.
+func authArgs(authHeader http.Header, args ...string) []string {
+	if len(authHeader) == 0 {
+		return args
+	}
+	full := make([]string, 0, len(args)+2*len(authHeader))
+	for k, vs := range authHeader {
+		for _, v := range vs {
+			full = append(full, "-c", fmt.Sprintf("http.extraHeader=%s: %s", k, v))
+		}
+	}
+	return append(full, args...)
+}
+
+// fullSHARe matches a full 40-character hex commit SHA, which is
+// already an immutable ref and needs no further resolution.
+var fullSHARe = regexp.MustCompile(`^[0-9a-fA-F]{40}$`)
+
+// ResolveRef resolves ref (a branch, tag, or commit) on the remote
+// repoURL to its commit SHA. authHeader, if non-empty, is forwarded on
+// the remote requests this makes, the same way it is in Fetch.
+func ResolveRef(ctx context.Context, repoURL, ref string, authHeader http.Header) (string, error) {
+	if fullSHARe.MatchString(ref) {
+		return strings.ToLower(ref), nil
+	}
+
+	if sha, err := resolveViaLsRemote(ctx, repoURL, ref, authHeader); err == nil {
+		return sha, nil
+	}
+
+	// ls-remote only lists the branches and tags the remote advertises,
+	// not arbitrary commits, so a (possibly abbreviated) commit SHA
+	// falls through to an actual fetch instead.
+	return resolveViaFetch(ctx, repoURL, ref, authHeader)
+}
+
+// resolveViaLsRemote resolves ref via `git ls-remote`, which works for
+// branches and tags without fetching any objects.
+func resolveViaLsRemote(ctx context.Context, repoURL, ref string, authHeader http.Header) (string, error) {
+	cmd := exec.CommandContext(ctx, "git", authArgs(authHeader, "ls-remote", "--", repoURL, ref)...)
+	var out strings.Builder
+	cmd.Stdout = &out
+	cmd.Stderr = &out
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("gitfetch: ls-remote %s@%s: %s: %w", repoURL, ref, strings.TrimSpace(out.String()), err)
+	}
+
+	line := strings.SplitN(out.String(), "\t", 2)[0]
+	sha := strings.TrimSpace(line)
+	if sha == "" {
+		return "", fmt.Errorf("gitfetch: ref %q not found on %s", ref, repoURL)
+	}
+	return sha, nil
+}
+
+// resolveViaFetch resolves ref by fetching it into a scratch bare
+// repository and reading back the SHA it landed on. This is the only
+// way to resolve an abbreviated commit SHA (ResolveRef returns a full
+// one as is): it isn't a ref ls-remote can list, so the remote has to
+// be asked to fetch it.
+func resolveViaFetch(ctx context.Context, repoURL, ref string, authHeader http.Header) (string, error) {
+	scratchDir, err := os.MkdirTemp("", "gitfetch-resolve-")
+	if err != nil {
+		return "", fmt.Errorf("gitfetch: create scratch dir: %w", err)
+	}
+	defer os.RemoveAll(scratchDir)
+
+	if err := runGit(ctx, scratchDir, "init", "--quiet", "--bare"); err != nil {
+		return "", fmt.Errorf("gitfetch: init scratch repo: %w", err)
+	}
+	if err := runGit(ctx, scratchDir, authArgs(authHeader, "fetch", "--depth=1", "--filter=blob:none", "--", repoURL, ref)...); err != nil {
+		return "", fmt.Errorf("gitfetch: resolve %s@%s: %w", repoURL, ref, err)
+	}
+
+	sha, err := runGitOutput(ctx, scratchDir, "rev-parse", "FETCH_HEAD")
+	if err != nil {
+		return "", fmt.Errorf("gitfetch: resolve %s@%s: %w", repoURL, ref, err)
+	}
+	return strings.TrimSpace(sha), nil
+}
+
+func runGit(ctx context.Context, dir string, args ...string) error {
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Dir = dir
+	var out strings.Builder
+	cmd.Stdout = &out
+	cmd.Stderr = &out
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("%s: %w", strings.TrimSpace(out.String()), err)
+	}
+	return nil
+}
+
+func runGitOutput(ctx context.Context, dir string, args ...string) (string, error) {
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Dir = dir
+	var out, stderr strings.Builder
+	cmd.Stdout = &out
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("%s: %w", strings.TrimSpace(stderr.String()), err)
+	}
+	return out.String(), nil
+}
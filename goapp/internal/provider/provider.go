@@ -0,0 +1,190 @@
+// Package provider abstracts over the URL conventions and auth schemes
+// of the forges the service can archive from, so the request handlers
+// don't need to hard-code GitHub's layout.
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// fullSHARe matches a full 40-character hex commit SHA, which is
+// already an immutable ref and needs no API round-trip to resolve.
+var fullSHARe = regexp.MustCompile(`^[0-9a-fA-F]{40}$`)
+
+// Provider knows how to build archive URLs, resolve refs to commit
+// SHAs, and authenticate requests for one forge.
+type Provider interface {
+	// ArchiveURL returns the zip download URL for owner/repo at ref, or
+	// "" if this provider has no zip endpoint and repositories must be
+	// fetched through the git backend instead (see the generic provider).
+	ArchiveURL(owner, repo, ref string) string
+
+	// ResolveRef resolves ref to an immutable commit SHA.
+	ResolveRef(ctx context.Context, owner, repo, ref string) (sha string, err error)
+
+	// AuthHeader returns the header(s) to attach to outgoing requests
+	// for this provider, forwarding the caller's Authorization header
+	// if present or falling back to a per-provider env var token.
+	AuthHeader(r *http.Request) http.Header
+}
+
+// Select picks a Provider for host, the first path segment of the
+// incoming request (e.g. "github.com", "gitlab.example.com"). An
+// X-Provider header, if present, picks which forge's conventions to use
+// instead of guessing from host (useful for self-hosted instances whose
+// domain doesn't contain the forge's name), but host itself still
+// anchors the URLs the provider builds. Hosts that don't match a known
+// forge fall back to the generic git provider.
+func Select(host string, headers http.Header) Provider {
+	kind := host
+	if h := headers.Get("X-Provider"); h != "" {
+		kind = h
+	}
+
+	switch {
+	case strings.Contains(kind, "github"):
+		return &forge{kind: "github", host: host, archivePattern: "archive/%[3]s.zip", envToken: "GITHUB_TOKEN", authScheme: "Bearer"}
+	case strings.Contains(kind, "gitlab"):
+		return &forge{kind: "gitlab", host: host, archivePattern: "-/archive/%[3]s/%[2]s-%[3]s.zip", envToken: "GITLAB_TOKEN", authHeader: "PRIVATE-TOKEN"}
+	case strings.Contains(kind, "bitbucket"):
+		return &forge{kind: "bitbucket", host: host, archivePattern: "get/%[3]s.zip", envToken: "BITBUCKET_TOKEN", authScheme: "Bearer"}
+	case strings.Contains(kind, "gitea"):
+		return &forge{kind: "gitea", host: host, archivePattern: "archive/%[3]s.zip", envToken: "GITEA_TOKEN", authScheme: "token"}
+	default:
+		return &genericGit{host: host}
+	}
+}
+
+// forge implements Provider for the common case of a forge that serves
+// a zip archive at a URL built from a per-forge pattern. ResolveRef is
+// the one method whose URL shape and response body genuinely differ
+// between forges, so it branches on kind instead of sharing one
+// endpoint convention.
+type forge struct {
+	kind           string // "github", "gitlab", "bitbucket", or "gitea"; picks ResolveRef's endpoint
+	host           string
+	archivePattern string // fmt pattern over (host, repo, ref); ArchiveURL prepends https://host/owner/repo/
+	envToken       string
+	authHeader     string // header name for the token, e.g. "PRIVATE-TOKEN"; defaults to "Authorization"
+	authScheme     string // scheme prefix when authHeader is "Authorization", e.g. "Bearer", "token"
+}
+
+func (f *forge) ArchiveURL(owner, repo, ref string) string {
+	suffix := fmt.Sprintf(f.archivePattern, f.host, repo, ref)
+	return fmt.Sprintf("https://%s/%s/%s/%s", f.host, owner, repo, suffix)
+}
+
+// ResolveRef resolves ref to a commit SHA via the forge's own API, since
+// GitHub, GitLab, Bitbucket, and Gitea each use a different endpoint and
+// a different field name for the SHA in their response.
+func (f *forge) ResolveRef(ctx context.Context, owner, repo, ref string) (string, error) {
+	if fullSHARe.MatchString(ref) {
+		return strings.ToLower(ref), nil
+	}
+
+	switch f.kind {
+	case "github":
+		resolveURL := fmt.Sprintf("https://%s/%s/%s/commits/%s", f.host, owner, repo, ref)
+		return f.resolveRefJSON(ctx, resolveURL, "application/vnd.github+json", "sha")
+	case "gitea":
+		resolveURL := fmt.Sprintf("https://%s/api/v1/repos/%s/%s/git/commits/%s", f.host, owner, repo, ref)
+		return f.resolveRefJSON(ctx, resolveURL, "application/json", "sha")
+	case "gitlab":
+		projectID := url.QueryEscape(owner + "/" + repo)
+		resolveURL := fmt.Sprintf("https://%s/api/v4/projects/%s/repository/commits/%s", f.host, projectID, ref)
+		return f.resolveRefJSON(ctx, resolveURL, "application/json", "id")
+	case "bitbucket":
+		resolveURL := fmt.Sprintf("https://%s/2.0/repositories/%s/%s/commit/%s", f.host, owner, repo, ref)
+		return f.resolveRefJSON(ctx, resolveURL, "application/json", "hash")
+	default:
+		return "", fmt.Errorf("provider: %s has no known ResolveRef endpoint", f.kind)
+	}
+}
+
+// resolveRefJSON GETs resolveURL and decodes shaField out of the JSON
+// response as the resolved commit SHA. The four forge APIs that go
+// through here (GitHub, GitLab via "id", Bitbucket via "hash", Gitea)
+// all return a flat JSON object with the SHA as a single string field,
+// just under different names.
+func (f *forge) resolveRefJSON(ctx context.Context, resolveURL, accept, shaField string) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, resolveURL, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Accept", accept)
+	if token := os.Getenv(f.envToken); token != "" {
+		f.setAuth(req.Header, token)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("resolve ref: %s", resp.Status)
+	}
+
+	var commit map[string]interface{}
+	if err := json.NewDecoder(resp.Body).Decode(&commit); err != nil {
+		return "", fmt.Errorf("decode commit response: %w", err)
+	}
+	sha, _ := commit[shaField].(string)
+	if sha == "" {
+		return "", fmt.Errorf("no %s in commit response", shaField)
+	}
+	return sha, nil
+}
+
+func (f *forge) AuthHeader(r *http.Request) http.Header {
+	h := make(http.Header)
+	if auth := r.Header.Get("Authorization"); auth != "" {
+		h.Set("Authorization", auth)
+		return h
+	}
+	if token := os.Getenv(f.envToken); token != "" {
+		f.setAuth(h, token)
+	}
+	return h
+}
+
+func (f *forge) setAuth(h http.Header, token string) {
+	name := f.authHeader
+	if name == "" {
+		name = "Authorization"
+	}
+	if name == "Authorization" && f.authScheme != "" {
+		h.Set(name, f.authScheme+" "+token)
+		return
+	}
+	h.Set(name, token)
+}
+
+// genericGit is the fallback provider for self-hosted or unrecognized
+// forges: it has no zip endpoint, so callers must use the git backend
+// (internal/gitfetch) to fetch repositories it serves.
+type genericGit struct {
+	host string
+}
+
+func (g *genericGit) ArchiveURL(owner, repo, ref string) string { return "" }
+
+func (g *genericGit) ResolveRef(ctx context.Context, owner, repo, ref string) (string, error) {
+	return "", fmt.Errorf("provider: %s has no archive API; resolve refs via gitfetch.ResolveRef instead", g.host)
+}
+
+func (g *genericGit) AuthHeader(r *http.Request) http.Header {
+	h := make(http.Header)
+	if auth := r.Header.Get("Authorization"); auth != "" {
+		h.Set("Authorization", auth)
+	}
+	return h
+}
@@ -0,0 +1,42 @@
+// Package safefs guards against path-traversal entries ("zip-slip")
+// when resolving an archive entry's name against a destination path.
+package safefs
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// ErrPathEscape is returned (wrapped) by Join when an entry name would
+// resolve outside of the destination directory.
+var ErrPathEscape = errors.New("safefs: entry escapes destination directory")
+
+// Join resolves name against dest the way an archive extractor would,
+// rejecting names that are absolute (including Windows drive-letter
+// paths, regardless of GOOS, since the name is attacker-controlled) or
+// that use ".." segments to climb outside dest.
+func Join(dest, name string) (string, error) {
+	if name == "" {
+		return "", fmt.Errorf("safefs: empty entry name")
+	}
+	if filepath.IsAbs(name) || hasWindowsDrive(name) {
+		return "", fmt.Errorf("%w: %q is an absolute path", ErrPathEscape, name)
+	}
+
+	cleanDest := filepath.Clean(dest)
+	joined := filepath.Join(cleanDest, name)
+	if joined != cleanDest && !strings.HasPrefix(joined, cleanDest+string(filepath.Separator)) {
+		return "", fmt.Errorf("%w: %q", ErrPathEscape, name)
+	}
+	return joined, nil
+}
+
+func hasWindowsDrive(name string) bool {
+	if len(name) < 2 || name[1] != ':' {
+		return false
+	}
+	c := name[0]
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
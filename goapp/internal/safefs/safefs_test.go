@@ -0,0 +1,47 @@
+package safefs
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+func TestJoinRejectsEscapes(t *testing.T) {
+	dest := filepath.FromSlash("/tmp/extract-root")
+
+	cases := []string{
+		"../outside",
+		"a/../../outside",
+		"../../../etc/passwd",
+		"/etc/passwd",
+		`C:\Windows\System32`,
+		"",
+	}
+	for _, name := range cases {
+		if _, err := Join(dest, name); !errors.Is(err, ErrPathEscape) && name != "" {
+			t.Errorf("Join(%q, %q): want ErrPathEscape, got %v", dest, name, err)
+		} else if name == "" && err == nil {
+			t.Errorf("Join(%q, %q): want error for empty name, got nil", dest, name)
+		}
+	}
+}
+
+func TestJoinAllowsSafeNames(t *testing.T) {
+	dest := filepath.FromSlash("/tmp/extract-root")
+
+	cases := map[string]string{
+		"file.txt":         filepath.Join(dest, "file.txt"),
+		"a/b/c.txt":        filepath.Join(dest, "a/b/c.txt"),
+		"./a/./b/../b.txt": filepath.Join(dest, "a/b.txt"),
+	}
+	for name, want := range cases {
+		got, err := Join(dest, name)
+		if err != nil {
+			t.Errorf("Join(%q, %q): unexpected error: %v", dest, name, err)
+			continue
+		}
+		if got != want {
+			t.Errorf("Join(%q, %q) = %q, want %q", dest, name, got, want)
+		}
+	}
+}
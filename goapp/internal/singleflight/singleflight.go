@@ -0,0 +1,49 @@
+// Package singleflight collapses concurrent callers asking for the same
+// key into a single in-flight call, so identical requests share one
+// build instead of racing to redo the same work.
+package singleflight
+
+import "sync"
+
+// Group deduplicates concurrent calls sharing the same key.
+type Group struct {
+	mu    sync.Mutex
+	calls map[string]*call
+}
+
+type call struct {
+	wg  sync.WaitGroup
+	val interface{}
+	err error
+}
+
+// Do executes fn, making sure only one execution is in flight for a
+// given key at a time. Concurrent callers with the same key wait for
+// the first call to finish and receive its result; shared reports
+// whether this caller received another caller's result instead of
+// running fn itself (it is false for the caller that ran fn).
+func (g *Group) Do(key string, fn func() (interface{}, error)) (val interface{}, err error, shared bool) {
+	g.mu.Lock()
+	if g.calls == nil {
+		g.calls = make(map[string]*call)
+	}
+	if c, ok := g.calls[key]; ok {
+		g.mu.Unlock()
+		c.wg.Wait()
+		return c.val, c.err, true
+	}
+
+	c := new(call)
+	c.wg.Add(1)
+	g.calls[key] = c
+	g.mu.Unlock()
+
+	c.val, c.err = fn()
+	c.wg.Done()
+
+	g.mu.Lock()
+	delete(g.calls, key)
+	g.mu.Unlock()
+
+	return c.val, c.err, false
+}
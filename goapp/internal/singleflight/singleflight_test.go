@@ -0,0 +1,71 @@
+package singleflight
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestDoCollapsesConcurrentCalls(t *testing.T) {
+	var g Group
+	var calls int32
+
+	const n = 20
+	var wg sync.WaitGroup
+	results := make([]int, n)
+	shared := make([]bool, n)
+
+	start := make(chan struct{})
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			<-start
+			v, err, isShared := g.Do("key", func() (interface{}, error) {
+				atomic.AddInt32(&calls, 1)
+				// Hold the call in flight long enough for the other
+				// goroutines to reach Do and collapse onto it.
+				time.Sleep(50 * time.Millisecond)
+				return 42, nil
+			})
+			if err != nil {
+				t.Errorf("Do: unexpected error: %v", err)
+			}
+			results[i] = v.(int)
+			shared[i] = isShared
+		}(i)
+	}
+	close(start)
+	wg.Wait()
+
+	if got := atomic.LoadInt32(&calls); got != 1 {
+		t.Errorf("fn called %d times, want exactly 1", got)
+	}
+	for i, r := range results {
+		if r != 42 {
+			t.Errorf("result[%d] = %d, want 42", i, r)
+		}
+	}
+}
+
+func TestDoRunsAgainAfterCompletion(t *testing.T) {
+	var g Group
+	var calls int32
+
+	fn := func() (interface{}, error) {
+		atomic.AddInt32(&calls, 1)
+		return nil, nil
+	}
+
+	if _, err, _ := g.Do("key", fn); err != nil {
+		t.Fatalf("Do: %v", err)
+	}
+	if _, err, _ := g.Do("key", fn); err != nil {
+		t.Fatalf("Do: %v", err)
+	}
+
+	if got := atomic.LoadInt32(&calls); got != 2 {
+		t.Errorf("fn called %d times across two sequential Do calls, want 2", got)
+	}
+}
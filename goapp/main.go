@@ -1,19 +1,28 @@
 package main
 
 import (
-	"archive/tar"
 	"archive/zip"
-	"compress/gzip"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
+	"net/url"
 	"os"
 	"path/filepath"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
+
+	"goapp/internal/cache"
+	"goapp/internal/gitfetch"
+	"goapp/internal/provider"
+	"goapp/internal/safefs"
+	"goapp/internal/singleflight"
+	"goapp/pkg/archive"
 )
 
 type PostRequestBody struct {
@@ -22,6 +31,80 @@ type PostRequestBody struct {
 	TargetRevision string `json:"targetRevision"`
 }
 
+// requestError carries the HTTP status a failure should be reported
+// with, distinguishing client-facing problems (e.g. a missing path)
+// from opaque internal errors in the pipeline below processRequest.
+type requestError struct {
+	status int
+	msg    string
+	err    error
+}
+
+func (e *requestError) Error() string { return fmt.Sprintf("%s: %v", e.msg, e.err) }
+func (e *requestError) Unwrap() error { return e.err }
+
+var (
+	archiveCache     *cache.Cache
+	archiveCacheOnce sync.Once
+	buildGroup       singleflight.Group
+)
+
+// getCache lazily initializes the on-disk archive cache from the
+// CACHE_DIR, CACHE_MAX_BYTES, and CACHE_MAX_ENTRIES environment
+// variables, falling back to sane defaults.
+func getCache() *cache.Cache {
+	archiveCacheOnce.Do(func() {
+		dir := os.Getenv("CACHE_DIR")
+		if dir == "" {
+			dir = filepath.Join(os.TempDir(), "repo-archive-cache")
+		}
+
+		c, err := cache.New(dir, envInt64("CACHE_MAX_BYTES", 10<<30), envInt("CACHE_MAX_ENTRIES", 1000))
+		if err != nil {
+			log.Fatalf("Failed to initialize archive cache: %v", err)
+		}
+		archiveCache = c
+	})
+	return archiveCache
+}
+
+func envInt64(name string, def int64) int64 {
+	v, err := strconv.ParseInt(os.Getenv(name), 10, 64)
+	if err != nil {
+		return def
+	}
+	return v
+}
+
+func envInt(name string, def int) int {
+	v, err := strconv.Atoi(os.Getenv(name))
+	if err != nil {
+		return def
+	}
+	return v
+}
+
+// limits bounds how much a single request is allowed to download and
+// archive, so a malicious or oversized repository can't exhaust disk,
+// memory, or bandwidth. All fields are configurable via environment
+// variables, read by defaultLimits each time it is called (once per
+// build).
+type limits struct {
+	maxDownloadBytes int64 // cap on the raw download from the forge
+	maxEntryBytes    int64 // cap on any single archive entry, uncompressed
+	maxTotalBytes    int64 // cap on the sum of all entries, uncompressed
+	maxEntries       int   // cap on the number of entries
+}
+
+func defaultLimits() limits {
+	return limits{
+		maxDownloadBytes: envInt64("MAX_DOWNLOAD_BYTES", 1<<30), // 1 GiB
+		maxEntryBytes:    envInt64("MAX_ENTRY_BYTES", 512<<20),  // 512 MiB
+		maxTotalBytes:    envInt64("MAX_TOTAL_BYTES", 2<<30),    // 2 GiB
+		maxEntries:       envInt("MAX_ENTRIES", 50000),
+	}
+}
+
 func main() {
 	http.HandleFunc("/", handleRequest)
 
@@ -57,15 +140,30 @@ func handleGetRequest(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Extract the provider, owner, repo, and optional subdirectory path
-	provider, owner, repo := parts[0], parts[1], parts[2]
+	// Extract the provider host, owner, repo, and optional subdirectory path
+	host, owner, repo := parts[0], parts[1], parts[2]
 	subPath := "" // default subdirectory path is empty
 
+	// A trailing "archive.<ext>" segment selects the output format via
+	// the URL itself, e.g. /github.com/o/r/path/archive.tar.bz2
+	format := archive.TarGz
+	if len(parts) > 3 {
+		last := parts[len(parts)-1]
+		if ext, ok := strings.CutPrefix(last, "archive."); ok {
+			if f, ok := archive.ParseFormat(ext); ok {
+				format = f
+				parts = parts[:len(parts)-1]
+			}
+		}
+	}
+
 	// If there are more parts, the remaining part is the path within the repo
 	if len(parts) > 3 {
 		subPath = strings.Join(parts[3:], "/")
 	}
 
+	format = resolveFormat(r, format)
+
 	// Default ref and timeout values
 	ref := "main"  // default ref
 	timeout := 120 // default timeout
@@ -87,11 +185,29 @@ func handleGetRequest(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	// Construct the repo URL based on the provider
-	repoURL := fmt.Sprintf("https://%s/%s/%s", provider, owner, repo)
+	// Construct the repo URL based on the provider host
+	repoURL := fmt.Sprintf("https://%s/%s/%s", host, owner, repo)
+	prov := provider.Select(host, r.Header)
 
 	// Call the function to process the request with the provided parameters
-	processRequest(w, r, repoURL, repo, ref, subPath, timeout)
+	processRequest(w, r, prov, repoURL, owner, repo, ref, subPath, timeout, format)
+}
+
+// resolveFormat picks the archive format from the `format` query param or
+// the `Accept` header, falling back to def (the format implied, if any,
+// by the request URL itself).
+func resolveFormat(r *http.Request, def archive.Format) archive.Format {
+	if fp := r.URL.Query().Get("format"); fp != "" {
+		if f, ok := archive.ParseFormat(fp); ok {
+			return f
+		}
+	}
+	if accept := r.Header.Get("Accept"); accept != "" {
+		if f, ok := archive.ParseFormat(accept); ok {
+			return f
+		}
+	}
+	return def
 }
 
 func handlePostRequest(w http.ResponseWriter, r *http.Request) {
@@ -106,159 +222,406 @@ func handlePostRequest(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	parts := strings.Split(strings.Trim(requestBody.RepoURL, "/"), "/")
-	processRequest(w, r, requestBody.RepoURL, parts[4], requestBody.TargetRevision, requestBody.Path, 20)
+	u, err := url.Parse(requestBody.RepoURL)
+	if err != nil {
+		http.Error(w, "Invalid repoURL", http.StatusBadRequest)
+		return
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		// Both backends end up shelling out (git or an HTTP download),
+		// so anything other than http(s) here is a way to smuggle a
+		// local path or an exotic git transport (ext::, ssh with a
+		// -oProxyCommand host) straight through to the subprocess.
+		http.Error(w, "repoURL must use http or https", http.StatusBadRequest)
+		return
+	}
+	segments := strings.Split(strings.Trim(u.Path, "/"), "/")
+	if len(segments) < 2 {
+		http.Error(w, "repoURL must include an owner and repo, e.g. https://github.com/owner/repo", http.StatusBadRequest)
+		return
+	}
+	owner, repo := segments[0], segments[1]
+
+	prov := provider.Select(u.Host, r.Header)
+	format := resolveFormat(r, archive.TarGz)
+	processRequest(w, r, prov, requestBody.RepoURL, owner, repo, requestBody.TargetRevision, requestBody.Path, 20, format)
 }
 
-func processRequest(w http.ResponseWriter, r *http.Request, repoURL, repo, ref, path string, timeout int) {
-	tmpDir, err := os.MkdirTemp("", "repo-download-")
+func processRequest(w http.ResponseWriter, r *http.Request, prov provider.Provider, repoURL, owner, repo, ref, path string, timeout int, format archive.Format) {
+	ctx, cancel := context.WithTimeout(r.Context(), time.Duration(timeout)*time.Second)
+	defer cancel()
+
+	archiveURL := prov.ArchiveURL(owner, repo, ref)
+	// git is the default fetch strategy now (sparse, shallow, and works
+	// against private repos and arbitrary hosts); ?backend=zip is an
+	// escape hatch back to the old whole-archive download for forges
+	// that expose one, kept around for comparison and as a fallback if
+	// a host's git-upload-pack is unreachable but its zip endpoint isn't.
+	gitBackend := archiveURL == "" || r.URL.Query().Get("backend") != "zip"
+	authHeader := prov.AuthHeader(r)
+
+	sha, err := resolveSHA(ctx, prov, repoURL, owner, repo, ref, gitBackend, authHeader)
 	if err != nil {
-		http.Error(w, "Failed to create temporary directory", http.StatusInternalServerError)
-		log.Printf("Error creating temp directory: %v", err)
+		http.Error(w, "Failed to resolve ref", http.StatusInternalServerError)
+		log.Printf("Error resolving %s@%s: %v", repoURL, ref, err)
 		return
 	}
-	defer os.RemoveAll(tmpDir)
 
-	zipURL := fmt.Sprintf("%s/archive/%s.zip", repoURL, ref)
-	zipPath := filepath.Join(tmpDir, "repo.zip")
-	if err := downloadFile(zipURL, zipPath); err != nil {
-		http.Error(w, "Failed to download repository", http.StatusInternalServerError)
-		log.Printf("Error downloading file from %s: %v", zipURL, err)
-		return
+	c := getCache()
+	key := cache.Key(repoURL, sha, path, string(format))
+	archiveName := format.FileName(repo)
+
+	if etag := `"` + sha + `"`; r.Header.Get("If-None-Match") == etag {
+		if _, ok := c.Lookup(key); ok {
+			w.Header().Set("ETag", etag)
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
 	}
 
-	extractPath := filepath.Join(tmpDir, "repo")
-	if err := unzip(zipPath, extractPath); err != nil {
-		http.Error(w, "Failed to extract ZIP file", http.StatusInternalServerError)
-		log.Printf("Error extracting zip file %s: %v", zipPath, err)
+	w.Header().Set("ETag", `"`+sha+`"`)
+	w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+	w.Header().Set("Content-Type", format.ContentType())
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%s", archiveName))
+
+	if cachedPath, ok := c.Lookup(key); ok {
+		http.ServeFile(w, r, cachedPath)
 		return
 	}
 
-	if path != "" {
-		fullPath := fmt.Sprintf("%s-%s/%s", repo, ref, path)
-		extractPath = filepath.Join(extractPath, fullPath)
-		if _, err := os.Stat(extractPath); os.IsNotExist(err) {
-			http.Error(w, "Specified path does not exist", http.StatusBadRequest)
-			log.Printf("Path does not exist: %s", extractPath)
+	// Headers are set above, before the build even starts: the caller
+	// whose request actually triggers the build (below) streams
+	// straight into the response as it's written to the cache file, so
+	// time-to-first-byte is the time to the first archive entry, not the
+	// whole build. A build error after bytes have already reached the
+	// client can't be turned into a clean HTTP error anymore — the
+	// response is logged and left truncated, same as this service's
+	// pre-cache streaming behavior.
+	cw := &countingWriter{w: w}
+	result, err, shared := buildGroup.Do(key, func() (interface{}, error) {
+		return buildCachedArchive(ctx, c, key, repoURL, archiveURL, repo, ref, path, gitBackend, format, authHeader, defaultLimits(), cw)
+	})
+	if err != nil {
+		if cw.n > 0 {
+			log.Printf("Error streaming archive for %s@%s: %v", repoURL, ref, err)
 			return
 		}
+		// No archive bytes reached the client yet, so nothing has been
+		// sent that the headers set above apply to. Strip them before
+		// reporting the error, or a caching proxy would learn to treat
+		// this error page as the immutable, year-long-cacheable response
+		// for this URL+ETag.
+		clearArchiveHeaders(w)
+		var re *requestError
+		if errors.As(err, &re) {
+			http.Error(w, re.msg, re.status)
+			log.Printf("%v", re)
+		} else {
+			http.Error(w, "Failed to build archive", http.StatusInternalServerError)
+			log.Printf("Error building archive for %s@%s: %v", repoURL, ref, err)
+		}
+		return
 	}
 
-	archiveName := fmt.Sprintf("repo-%d.tar.gz", time.Now().Unix())
-	archivePath := filepath.Join(tmpDir, archiveName)
-	if err := createTarGz(archivePath, extractPath); err != nil {
-		http.Error(w, "Failed to create archive", http.StatusInternalServerError)
-		log.Printf("Error creating tar.gz file %s: %v", archivePath, err)
-		return
+	// shared means another caller's Do call is the one that actually
+	// ran the closure above and streamed to its own response; this
+	// caller only has the finished cache entry to serve from.
+	if shared {
+		http.ServeFile(w, r, result.(string))
 	}
+}
 
-	w.Header().Set("Content-Type", "application/gzip")
-	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%s", archiveName))
-	http.ServeFile(w, r, archivePath)
+// clearArchiveHeaders removes the caching and content headers set
+// ahead of a build, used to undo that when the build fails before any
+// bytes were written.
+func clearArchiveHeaders(w http.ResponseWriter) {
+	h := w.Header()
+	h.Del("ETag")
+	h.Del("Cache-Control")
+	h.Del("Content-Type")
+	h.Del("Content-Disposition")
+}
+
+// countingWriter tracks whether anything has been written yet, so a
+// build failure can still be reported as a clean HTTP error as long as
+// no archive bytes have reached the client.
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
 }
 
-func downloadFile(url, dest string) error {
-	resp, err := http.Get(url)
+// buildCachedArchive runs the download/fetch-and-archive pipeline into a
+// fresh cache tempfile, committing it under key once finished, and
+// returns the final on-disk path. If live is non-nil, the build is
+// streamed to it (in addition to the cache tempfile) as it happens, so
+// the caller that triggered the build doesn't wait for the whole thing.
+func buildCachedArchive(ctx context.Context, c *cache.Cache, key, repoURL, archiveURL, repo, ref, path string, gitBackend bool, format archive.Format, authHeader http.Header, lim limits, live io.Writer) (string, error) {
+	tmpDir, err := os.MkdirTemp("", "repo-download-")
 	if err != nil {
-		return err
+		return "", fmt.Errorf("create temp directory: %w", err)
 	}
-	defer resp.Body.Close()
+	defer os.RemoveAll(tmpDir)
 
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("failed to download file: %s", resp.Status)
+	out, err := c.TempFile()
+	if err != nil {
+		return "", fmt.Errorf("create cache tempfile: %w", err)
 	}
+	tmpArchive := out.Name()
 
-	file, err := os.Create(dest)
-	if err != nil {
+	dest := io.Writer(out)
+	if live != nil {
+		dest = io.MultiWriter(out, live)
+	}
+
+	buildErr := buildArchive(ctx, tmpDir, repoURL, archiveURL, repo, ref, path, gitBackend, format, dest, authHeader, lim)
+	if closeErr := out.Close(); buildErr == nil {
+		buildErr = closeErr
+	}
+	if buildErr != nil {
+		os.Remove(tmpArchive)
+		return "", buildErr
+	}
+
+	return c.Put(tmpArchive, key)
+}
+
+// buildArchive fetches the repository (via the git backend or a forge
+// zip download) and streams the requested sub-path into out as format,
+// rejecting anything that breaches lim.
+func buildArchive(ctx context.Context, tmpDir, repoURL, archiveURL, repo, ref, path string, gitBackend bool, format archive.Format, out io.Writer, authHeader http.Header, lim limits) error {
+	if gitBackend {
+		localDir, err := gitfetch.Fetch(ctx, repoURL, ref, path, authHeader, gitfetch.Options{BaseDir: tmpDir, MaxBytes: lim.maxTotalBytes})
+		if err != nil {
+			if errors.Is(err, gitfetch.ErrTooLarge) {
+				return &requestError{status: http.StatusRequestEntityTooLarge, msg: "Repository exceeds configured archive limits", err: err}
+			}
+			return fmt.Errorf("fetch repository: %w", err)
+		}
+
+		extractPath := localDir
+		if path != "" {
+			extractPath, err = safefs.Join(localDir, path)
+			if err != nil {
+				return &requestError{status: http.StatusBadRequest, msg: "Specified path is invalid", err: err}
+			}
+			if _, err := os.Stat(extractPath); os.IsNotExist(err) {
+				return &requestError{status: http.StatusBadRequest, msg: "Specified path does not exist", err: err}
+			}
+		}
+
+		return writeArchive(ctx, out, format, extractPath, lim)
+	}
+
+	zipPath := filepath.Join(tmpDir, "repo.zip")
+	if err := downloadFile(ctx, archiveURL, zipPath, lim.maxDownloadBytes, authHeader); err != nil {
 		return err
 	}
-	defer file.Close()
 
-	_, err = io.Copy(file, resp.Body)
-	return err
+	prefix, err := zipEntryPrefix(zipPath, repo, ref, path)
+	if err != nil {
+		return &requestError{status: http.StatusBadRequest, msg: "Specified path does not exist", err: err}
+	}
+
+	return writeArchiveFromZip(ctx, out, format, zipPath, prefix, lim)
 }
 
-func unzip(src, dest string) error {
-	zipReader, err := zip.OpenReader(src)
+// resolveSHA resolves ref to an immutable commit SHA so the archive
+// cache can be keyed on something stable across requests.
+func resolveSHA(ctx context.Context, prov provider.Provider, repoURL, owner, repo, ref string, gitBackend bool, authHeader http.Header) (string, error) {
+	if gitBackend {
+		return gitfetch.ResolveRef(ctx, repoURL, ref, authHeader)
+	}
+	return prov.ResolveRef(ctx, owner, repo, ref)
+}
+
+// writeArchive walks sourceDir and streams its contents to w as an
+// archive in the given format, enforcing lim along the way and aborting
+// early if ctx is cancelled (e.g. the request's timeout expires).
+func writeArchive(ctx context.Context, w io.Writer, format archive.Format, sourceDir string, lim limits) error {
+	aw, err := archive.NewWriter(ctx, format, w)
 	if err != nil {
 		return err
 	}
-	defer zipReader.Close()
 
-	for _, file := range zipReader.File {
-		extractPath := filepath.Join(dest, file.Name)
-		log.Printf("Extracting file: %s", extractPath)
-		if file.FileInfo().IsDir() {
-			os.MkdirAll(extractPath, os.ModePerm)
-			continue
-		}
+	var entries int
+	var totalBytes int64
 
-		if err := os.MkdirAll(filepath.Dir(extractPath), os.ModePerm); err != nil {
+	walkErr := filepath.Walk(sourceDir, func(file string, fi os.FileInfo, err error) error {
+		if err != nil {
 			return err
 		}
-
-		outFile, err := os.OpenFile(extractPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, file.Mode())
-		if err != nil {
+		if err := ctx.Err(); err != nil {
 			return err
 		}
+		if fi.IsDir() {
+			return nil
+		}
+		if fi.Mode()&os.ModeSymlink != 0 {
+			log.Printf("Skipping symlink entry: %s", file)
+			return nil
+		}
+
+		entries++
+		totalBytes += fi.Size()
+		if entries > lim.maxEntries || fi.Size() > lim.maxEntryBytes || totalBytes > lim.maxTotalBytes {
+			return &requestError{status: http.StatusRequestEntityTooLarge, msg: "Repository exceeds configured archive limits", err: fmt.Errorf("entries=%d size=%d", entries, totalBytes)}
+		}
 
-		rc, err := file.Open()
+		name, err := filepath.Rel(sourceDir, file)
 		if err != nil {
-			outFile.Close()
 			return err
 		}
 
-		_, err = io.Copy(outFile, rc)
-		outFile.Close()
-		rc.Close()
+		srcFile, err := os.Open(file)
 		if err != nil {
 			return err
 		}
+		defer srcFile.Close()
+
+		return aw.WriteFile(name, fi.Mode(), fi.ModTime(), fi.Size(), srcFile)
+	})
+	if walkErr != nil {
+		aw.Close()
+		return walkErr
 	}
 
-	return nil
+	return aw.Close()
 }
 
-func createTarGz(outputPath, sourceDir string) error {
-	file, err := os.Create(outputPath)
+// zipEntryPrefix returns the zip-internal directory prefix that holds
+// the requested subPath within a forge-generated archive (whose
+// top-level directory is named "<repo>-<ref>"), and errors if no entry
+// in zipPath matches it.
+func zipEntryPrefix(zipPath, repo, ref, subPath string) (string, error) {
+	zr, err := zip.OpenReader(zipPath)
+	if err != nil {
+		return "", err
+	}
+	defer zr.Close()
+
+	prefix := fmt.Sprintf("%s-%s/", repo, ref)
+	if subPath != "" {
+		prefix += subPath + "/"
+	}
+
+	for _, f := range zr.File {
+		if strings.HasPrefix(f.Name, prefix) {
+			return prefix, nil
+		}
+	}
+	return "", fmt.Errorf("no entry under %q", prefix)
+}
+
+// writeArchiveFromZip reads zipPath's entries directly and re-encodes
+// the ones under prefix as format, writing straight to w. It never
+// extracts the zip to disk: each entry's reader is piped straight into
+// the archive writer. Entry names are validated against safefs.Join to
+// reject zip-slip attempts, lim bounds the entry count and total
+// uncompressed size to defend against zip bombs, and ctx is checked
+// between entries so a cancelled request stops promptly.
+func writeArchiveFromZip(ctx context.Context, w io.Writer, format archive.Format, zipPath, prefix string, lim limits) error {
+	zr, err := zip.OpenReader(zipPath)
 	if err != nil {
 		return err
 	}
-	defer file.Close()
+	defer zr.Close()
 
-	gw := gzip.NewWriter(file)
-	defer gw.Close()
+	aw, err := archive.NewWriter(ctx, format, w)
+	if err != nil {
+		return err
+	}
 
-	tw := tar.NewWriter(gw)
-	defer tw.Close()
+	var entries int
+	var totalBytes int64
 
-	return filepath.Walk(sourceDir, func(file string, fi os.FileInfo, err error) error {
-		if err != nil {
+	for _, f := range zr.File {
+		if err := ctx.Err(); err != nil {
+			aw.Close()
 			return err
 		}
-
-		header, err := tar.FileInfoHeader(fi, fi.Name())
-		if err != nil {
-			return err
+		if !strings.HasPrefix(f.Name, prefix) {
+			continue
 		}
-
-		header.Name, _ = filepath.Rel(sourceDir, file)
-		log.Printf("Adding file to tar.gz: %s", header.Name)
-		if err := tw.WriteHeader(header); err != nil {
-			return err
+		name := strings.TrimPrefix(f.Name, prefix)
+		if name == "" || f.FileInfo().IsDir() {
+			continue
+		}
+		if f.Mode()&os.ModeSymlink != 0 {
+			log.Printf("Skipping symlink entry: %s", f.Name)
+			continue
+		}
+		if _, err := safefs.Join(".", name); err != nil {
+			aw.Close()
+			return &requestError{status: http.StatusBadRequest, msg: "Archive contains an unsafe entry name", err: err}
 		}
 
-		if !fi.Mode().IsRegular() {
-			return nil
+		entries++
+		size := int64(f.UncompressedSize64)
+		totalBytes += size
+		if entries > lim.maxEntries || size > lim.maxEntryBytes || totalBytes > lim.maxTotalBytes {
+			aw.Close()
+			return &requestError{status: http.StatusRequestEntityTooLarge, msg: "Archive exceeds configured limits", err: fmt.Errorf("entries=%d size=%d", entries, totalBytes)}
 		}
 
-		srcFile, err := os.Open(file)
-		if err != nil {
+		if err := func() error {
+			rc, err := f.Open()
+			if err != nil {
+				return err
+			}
+			defer rc.Close()
+			return aw.WriteFile(name, f.Mode(), f.Modified, size, rc)
+		}(); err != nil {
+			aw.Close()
 			return err
 		}
-		defer srcFile.Close()
+	}
 
-		_, err = io.Copy(tw, srcFile)
+	return aw.Close()
+}
+
+// downloadFile streams url to dest, attaching authHeader (if any) to the
+// request and aborting with a requestError if the response body exceeds
+// maxBytes. The request (and the copy loop, via io.Copy noticing the
+// response body error out once ctx is done) is bound to ctx so a stalled
+// or malicious server can't hold the download open past the caller's
+// timeout.
+func downloadFile(ctx context.Context, rawURL, dest string, maxBytes int64, authHeader http.Header) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
 		return err
-	})
+	}
+	for k, v := range authHeader {
+		req.Header[k] = v
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to download file: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to download file: %s", resp.Status)
+	}
+
+	file, err := os.Create(dest)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	n, err := io.Copy(file, io.LimitReader(resp.Body, maxBytes+1))
+	if err != nil {
+		return err
+	}
+	if n > maxBytes {
+		return &requestError{status: http.StatusRequestEntityTooLarge, msg: "Repository download exceeds the configured size limit", err: fmt.Errorf("exceeded %d bytes", maxBytes)}
+	}
+	return nil
 }
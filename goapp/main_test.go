@@ -0,0 +1,44 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"goapp/pkg/archive"
+)
+
+func TestResolveFormat(t *testing.T) {
+	cases := []struct {
+		name   string
+		query  string
+		accept string
+		def    archive.Format
+		want   archive.Format
+	}{
+		{name: "default when nothing requested", def: archive.TarGz, want: archive.TarGz},
+		{name: "format query param wins", query: "zip", def: archive.TarGz, want: archive.Zip},
+		{name: "Accept header used when no query param", accept: "application/x-bzip2", def: archive.TarGz, want: archive.TarBz2},
+		{name: "query param beats Accept header", query: "tar", accept: "application/zip", def: archive.TarGz, want: archive.Tar},
+		{name: "unrecognized query param falls through to Accept", query: "bogus", accept: "application/zip", def: archive.TarGz, want: archive.Zip},
+		{name: "unrecognized everything falls back to def", query: "bogus", accept: "bogus", def: archive.Tar, want: archive.Tar},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/owner/repo", nil)
+			if c.query != "" {
+				q := r.URL.Query()
+				q.Set("format", c.query)
+				r.URL.RawQuery = q.Encode()
+			}
+			if c.accept != "" {
+				r.Header.Set("Accept", c.accept)
+			}
+
+			if got := resolveFormat(r, c.def); got != c.want {
+				t.Errorf("resolveFormat() = %q, want %q", got, c.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,53 @@
+package archive
+
+import "strings"
+
+// Format identifies one of the archive encodings the service can emit.
+type Format string
+
+const (
+	Zip    Format = "zip"
+	Tar    Format = "tar"
+	TarGz  Format = "tar.gz"
+	TarBz2 Format = "tar.bz2"
+)
+
+// ParseFormat maps a URL suffix, an `Accept` header value, or a
+// `format=` query parameter to a known Format. It returns false if s
+// does not match any supported format.
+func ParseFormat(s string) (Format, bool) {
+	switch strings.ToLower(strings.TrimPrefix(s, ".")) {
+	case "zip", "application/zip":
+		return Zip, true
+	case "tar", "application/x-tar":
+		return Tar, true
+	case "tar.gz", "tgz", "application/gzip", "application/x-gzip":
+		return TarGz, true
+	case "tar.bz2", "tbz2", "application/x-bzip2":
+		return TarBz2, true
+	default:
+		return "", false
+	}
+}
+
+// ContentType returns the MIME type to send in the response for f.
+func (f Format) ContentType() string {
+	switch f {
+	case Zip:
+		return "application/zip"
+	case Tar:
+		return "application/x-tar"
+	case TarGz:
+		return "application/gzip"
+	case TarBz2:
+		return "application/x-bzip2"
+	default:
+		return "application/octet-stream"
+	}
+}
+
+// FileName returns the archive file name for f, given the base name of
+// the thing being archived (e.g. the repo name).
+func (f Format) FileName(base string) string {
+	return base + "." + string(f)
+}
@@ -0,0 +1,59 @@
+package archive
+
+import "testing"
+
+func TestParseFormat(t *testing.T) {
+	cases := []struct {
+		in     string
+		want   Format
+		wantOK bool
+	}{
+		{"zip", Zip, true},
+		{".zip", Zip, true},
+		{"application/zip", Zip, true},
+		{"TAR.GZ", TarGz, true},
+		{"tgz", TarGz, true},
+		{"application/x-gzip", TarGz, true},
+		{"tar", Tar, true},
+		{"application/x-tar", Tar, true},
+		{"tbz2", TarBz2, true},
+		{".tar.bz2", TarBz2, true},
+		{"rar", "", false},
+		{"", "", false},
+	}
+
+	for _, c := range cases {
+		got, ok := ParseFormat(c.in)
+		if ok != c.wantOK || got != c.want {
+			t.Errorf("ParseFormat(%q) = (%q, %v), want (%q, %v)", c.in, got, ok, c.want, c.wantOK)
+		}
+	}
+}
+
+func TestFormatContentType(t *testing.T) {
+	cases := []struct {
+		f    Format
+		want string
+	}{
+		{Zip, "application/zip"},
+		{Tar, "application/x-tar"},
+		{TarGz, "application/gzip"},
+		{TarBz2, "application/x-bzip2"},
+		{Format("bogus"), "application/octet-stream"},
+	}
+
+	for _, c := range cases {
+		if got := c.f.ContentType(); got != c.want {
+			t.Errorf("%q.ContentType() = %q, want %q", c.f, got, c.want)
+		}
+	}
+}
+
+func TestFormatFileName(t *testing.T) {
+	if got, want := TarGz.FileName("repo"), "repo.tar.gz"; got != want {
+		t.Errorf("TarGz.FileName(%q) = %q, want %q", "repo", got, want)
+	}
+	if got, want := Zip.FileName("repo"), "repo.zip"; got != want {
+		t.Errorf("Zip.FileName(%q) = %q, want %q", "repo", got, want)
+	}
+}
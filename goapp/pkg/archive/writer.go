@@ -0,0 +1,171 @@
+// Package archive provides a uniform streaming writer over the archive
+// formats the service can emit (zip, tar, tar.gz, tar.bz2), so callers
+// don't need to branch on format when adding entries.
+package archive
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"time"
+)
+
+// Writer streams archive entries to the underlying io.Writer given to
+// NewWriter. Entries must be written in order; Close must be called to
+// flush trailing format data (and is not safe to call twice).
+type Writer interface {
+	// WriteFile adds a single file entry. r is read to completion and
+	// closed by the caller, not by WriteFile.
+	WriteFile(name string, mode os.FileMode, modTime time.Time, size int64, r io.Reader) error
+	Close() error
+}
+
+// NewWriter returns a Writer that encodes entries as format and writes
+// the result to w. ctx bounds formats that shell out to an external
+// process (currently TarBz2); it is ignored by the others.
+func NewWriter(ctx context.Context, format Format, w io.Writer) (Writer, error) {
+	switch format {
+	case Zip:
+		return &zipWriter{zw: zip.NewWriter(w)}, nil
+	case Tar:
+		return &tarWriter{tw: tar.NewWriter(w)}, nil
+	case TarGz:
+		gw := gzip.NewWriter(w)
+		return &tarWriter{tw: tar.NewWriter(gw), closer: gw}, nil
+	case TarBz2:
+		return newBzip2TarWriter(ctx, w)
+	default:
+		return nil, fmt.Errorf("archive: unsupported format %q", format)
+	}
+}
+
+type tarWriter struct {
+	tw     *tar.Writer
+	closer io.Closer // optional, e.g. the gzip.Writer underneath tw
+}
+
+func (t *tarWriter) WriteFile(name string, mode os.FileMode, modTime time.Time, size int64, r io.Reader) error {
+	if err := t.tw.WriteHeader(&tar.Header{
+		Name:    name,
+		Mode:    int64(mode.Perm()),
+		Size:    size,
+		ModTime: modTime,
+	}); err != nil {
+		return err
+	}
+	_, err := io.Copy(t.tw, r)
+	return err
+}
+
+func (t *tarWriter) Close() error {
+	if err := t.tw.Close(); err != nil {
+		return err
+	}
+	if t.closer != nil {
+		return t.closer.Close()
+	}
+	return nil
+}
+
+type zipWriter struct {
+	zw *zip.Writer
+}
+
+func (z *zipWriter) WriteFile(name string, mode os.FileMode, modTime time.Time, size int64, r io.Reader) error {
+	hdr := &zip.FileHeader{
+		Name:     name,
+		Method:   zip.Deflate,
+		Modified: modTime,
+	}
+	hdr.SetMode(mode)
+	out, err := z.zw.CreateHeader(hdr)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(out, r)
+	return err
+}
+
+func (z *zipWriter) Close() error {
+	return z.zw.Close()
+}
+
+// bzip2TarWriter shells out to the `bzip2` binary, since the standard
+// library only ships a bzip2 reader. A tar.Writer feeds bzip2's stdin
+// over a pipe and bzip2's stdout is copied straight to the response.
+type bzip2TarWriter struct {
+	tw   *tar.Writer
+	pw   *io.PipeWriter
+	cmd  *exec.Cmd
+	done chan error
+}
+
+func newBzip2TarWriter(ctx context.Context, w io.Writer) (*bzip2TarWriter, error) {
+	if _, err := exec.LookPath("bzip2"); err != nil {
+		return nil, fmt.Errorf("archive: tar.bz2 output requires the bzip2 binary, which isn't installed: %w", err)
+	}
+
+	pr, pw := io.Pipe()
+	cmd := exec.CommandContext(ctx, "bzip2", "-c")
+	cmd.Stdin = pr
+	cmd.Stdout = w
+
+	b := &bzip2TarWriter{pw: pw, cmd: cmd, done: make(chan error, 1)}
+	b.tw = tar.NewWriter(pw)
+
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("archive: start bzip2: %w", err)
+	}
+
+	go func() {
+		waitErr := cmd.Wait()
+		// Once bzip2 has exited, nothing reads from pr again. Without
+		// this, a Write already blocked handing a chunk to the
+		// now-dead child's stdin (via os/exec's internal copy
+		// goroutine) would never unblock, wedging WriteFile/Close
+		// forever and, since this all runs inside buildGroup.Do,
+		// permanently jamming that cache key's singleflight entry.
+		pw.CloseWithError(waitErr)
+		b.done <- waitErr
+	}()
+
+	// CommandContext kills the process on cancellation, which the
+	// goroutine above will observe via cmd.Wait() — but that can race
+	// with a Write already stuck mid-pipe, so close pw directly too as
+	// soon as ctx is done, rather than waiting on cmd.Wait() to notice.
+	go func() {
+		<-ctx.Done()
+		pw.CloseWithError(ctx.Err())
+	}()
+
+	return b, nil
+}
+
+func (b *bzip2TarWriter) WriteFile(name string, mode os.FileMode, modTime time.Time, size int64, r io.Reader) error {
+	if err := b.tw.WriteHeader(&tar.Header{
+		Name:    name,
+		Mode:    int64(mode.Perm()),
+		Size:    size,
+		ModTime: modTime,
+	}); err != nil {
+		return err
+	}
+	_, err := io.Copy(b.tw, r)
+	return err
+}
+
+func (b *bzip2TarWriter) Close() error {
+	if err := b.tw.Close(); err != nil {
+		b.pw.Close()
+		return err
+	}
+	if err := b.pw.Close(); err != nil {
+		return err
+	}
+	return <-b.done
+}
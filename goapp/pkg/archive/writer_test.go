@@ -0,0 +1,174 @@
+package archive
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWriterRoundTripTarVariants(t *testing.T) {
+	for _, format := range []Format{Tar, TarGz} {
+		t.Run(string(format), func(t *testing.T) {
+			var buf bytes.Buffer
+			w, err := NewWriter(context.Background(), format, &buf)
+			if err != nil {
+				t.Fatalf("NewWriter(%s): %v", format, err)
+			}
+			body := "hello, world"
+			if err := w.WriteFile("a/b.txt", 0o644, time.Unix(0, 0), int64(len(body)), strings.NewReader(body)); err != nil {
+				t.Fatalf("WriteFile: %v", err)
+			}
+			if err := w.Close(); err != nil {
+				t.Fatalf("Close: %v", err)
+			}
+
+			tr := tarReader(t, format, &buf)
+			hdr, err := tr.Next()
+			if err != nil {
+				t.Fatalf("tar Next: %v", err)
+			}
+			if hdr.Name != "a/b.txt" {
+				t.Errorf("entry name = %q, want %q", hdr.Name, "a/b.txt")
+			}
+			got, err := io.ReadAll(tr)
+			if err != nil {
+				t.Fatalf("read entry: %v", err)
+			}
+			if string(got) != body {
+				t.Errorf("entry content = %q, want %q", got, body)
+			}
+		})
+	}
+}
+
+func tarReader(t *testing.T, format Format, buf *bytes.Buffer) *tar.Reader {
+	t.Helper()
+	if format == TarGz {
+		gr, err := gzip.NewReader(buf)
+		if err != nil {
+			t.Fatalf("gzip.NewReader: %v", err)
+		}
+		return tar.NewReader(gr)
+	}
+	return tar.NewReader(buf)
+}
+
+func TestWriterRoundTripZip(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := NewWriter(context.Background(), Zip, &buf)
+	if err != nil {
+		t.Fatalf("NewWriter(zip): %v", err)
+	}
+	body := "hello, zip"
+	if err := w.WriteFile("a/b.txt", 0o644, time.Unix(0, 0), int64(len(body)), strings.NewReader(body)); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatalf("zip.NewReader: %v", err)
+	}
+	if len(zr.File) != 1 {
+		t.Fatalf("len(zr.File) = %d, want 1", len(zr.File))
+	}
+	f := zr.File[0]
+	if f.Name != "a/b.txt" {
+		t.Errorf("entry name = %q, want %q", f.Name, "a/b.txt")
+	}
+	rc, err := f.Open()
+	if err != nil {
+		t.Fatalf("open entry: %v", err)
+	}
+	defer rc.Close()
+	got, err := io.ReadAll(rc)
+	if err != nil {
+		t.Fatalf("read entry: %v", err)
+	}
+	if string(got) != body {
+		t.Errorf("entry content = %q, want %q", got, body)
+	}
+}
+
+func TestNewWriterUnsupportedFormat(t *testing.T) {
+	_, err := NewWriter(context.Background(), Format("bogus"), io.Discard)
+	if err == nil {
+		t.Fatal("NewWriter with an unsupported format: want error, got nil")
+	}
+}
+
+func TestBzip2TarWriterRoundTrip(t *testing.T) {
+	if _, err := exec.LookPath("bzip2"); err != nil {
+		t.Skip("bzip2 binary not installed")
+	}
+
+	var buf bytes.Buffer
+	w, err := NewWriter(context.Background(), TarBz2, &buf)
+	if err != nil {
+		t.Fatalf("NewWriter(tar.bz2): %v", err)
+	}
+	body := "hello, bzip2"
+	if err := w.WriteFile("a/b.txt", 0o644, time.Unix(0, 0), int64(len(body)), strings.NewReader(body)); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	cmd := exec.Command("bzip2", "-dc")
+	cmd.Stdin = bytes.NewReader(buf.Bytes())
+	decompressed, err := cmd.Output()
+	if err != nil {
+		t.Fatalf("decompress with bzip2 -dc: %v", err)
+	}
+
+	tr := tar.NewReader(bytes.NewReader(decompressed))
+	hdr, err := tr.Next()
+	if err != nil {
+		t.Fatalf("tar Next: %v", err)
+	}
+	if hdr.Name != "a/b.txt" {
+		t.Errorf("entry name = %q, want %q", hdr.Name, "a/b.txt")
+	}
+	got, err := io.ReadAll(tr)
+	if err != nil {
+		t.Fatalf("read entry: %v", err)
+	}
+	if string(got) != body {
+		t.Errorf("entry content = %q, want %q", got, body)
+	}
+}
+
+func TestBzip2TarWriterCancelUnblocksWrite(t *testing.T) {
+	if _, err := exec.LookPath("bzip2"); err != nil {
+		t.Skip("bzip2 binary not installed")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	w, err := NewWriter(ctx, TarBz2, io.Discard)
+	if err != nil {
+		t.Fatalf("NewWriter(tar.bz2): %v", err)
+	}
+	cancel()
+
+	done := make(chan error, 1)
+	go func() {
+		body := "hello"
+		done <- w.WriteFile("a.txt", 0o644, time.Unix(0, 0), int64(len(body)), strings.NewReader(body))
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("WriteFile did not return after ctx was cancelled; bzip2TarWriter deadlocked")
+	}
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"archive/zip"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeTestZip(t *testing.T, names ...string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "repo.zip")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatalf("create zip: %v", err)
+	}
+	defer f.Close()
+
+	zw := zip.NewWriter(f)
+	for _, name := range names {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatalf("create entry %s: %v", name, err)
+		}
+		if _, err := w.Write([]byte("data")); err != nil {
+			t.Fatalf("write entry %s: %v", name, err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatalf("close zip: %v", err)
+	}
+	return path
+}
+
+func TestZipEntryPrefixWholeRepo(t *testing.T) {
+	zipPath := writeTestZip(t, "repo-main/README.md", "repo-main/src/a.go")
+
+	prefix, err := zipEntryPrefix(zipPath, "repo", "main", "")
+	if err != nil {
+		t.Fatalf("zipEntryPrefix: %v", err)
+	}
+	if want := "repo-main/"; prefix != want {
+		t.Errorf("prefix = %q, want %q", prefix, want)
+	}
+}
+
+func TestZipEntryPrefixSubPath(t *testing.T) {
+	zipPath := writeTestZip(t, "repo-main/README.md", "repo-main/src/a.go")
+
+	prefix, err := zipEntryPrefix(zipPath, "repo", "main", "src")
+	if err != nil {
+		t.Fatalf("zipEntryPrefix: %v", err)
+	}
+	if want := "repo-main/src/"; prefix != want {
+		t.Errorf("prefix = %q, want %q", prefix, want)
+	}
+}
+
+func TestZipEntryPrefixMissingSubPath(t *testing.T) {
+	zipPath := writeTestZip(t, "repo-main/README.md")
+
+	if _, err := zipEntryPrefix(zipPath, "repo", "main", "does-not-exist"); err == nil {
+		t.Fatal("zipEntryPrefix with a missing sub-path: want error, got nil")
+	}
+}